@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// AuthTokenCache persists the SAML auth token returned from a successful
+// callback (and the `auth-token` OpenVPN pushes on subsequent reconnects) to
+// disk, the same way bitmask-vpn's auth_sip cache avoids re-running its
+// login flow on every connection. A valid cache entry lets samlvpn skip the
+// browser/SAML redirect entirely and feed the token straight into the
+// OpenVPN management interface.
+type AuthTokenCache struct {
+	// Path is the file the token is persisted to.
+	Path string
+
+	// Permissions is the file mode the cache file is written with. It
+	// should match Config.TempCredentialsPermissions, since the cached
+	// token is as sensitive as the credentials file.
+	Permissions os.FileMode
+}
+
+// NewAuthTokenCache returns an AuthTokenCache backed by path, writing with
+// perm permissions.
+func NewAuthTokenCache(path string, perm os.FileMode) *AuthTokenCache {
+	return &AuthTokenCache{Path: path, Permissions: perm}
+}
+
+// cachedAuthToken is the on-disk representation of a cached token.
+type cachedAuthToken struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Save persists token to the cache, expiring it after ttl.
+func (c *AuthTokenCache) Save(token string, ttl time.Duration) error {
+	entry := cachedAuthToken{
+		Token:     token,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return errors.Wrap(err, "could not encode auth token cache")
+	}
+
+	if err := os.WriteFile(c.Path, data, c.Permissions); err != nil {
+		return errors.Wrap(err, "could not write auth token cache")
+	}
+
+	// os.WriteFile's perm argument only applies when the file is created,
+	// so an existing cache file with a looser mode needs to be tightened
+	// explicitly.
+	if err := os.Chmod(c.Path, c.Permissions); err != nil {
+		return errors.Wrap(err, "could not set auth token cache permissions")
+	}
+
+	return nil
+}
+
+// Load returns the cached token if one exists and has not expired. If the
+// cache file doesn't exist, or its token has expired, it returns an empty
+// token and ok == false without error.
+func (c *AuthTokenCache) Load() (token string, ok bool, err error) {
+	data, err := os.ReadFile(c.Path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", false, nil
+		}
+		return "", false, errors.Wrap(err, "could not read auth token cache")
+	}
+
+	var entry cachedAuthToken
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return "", false, errors.Wrap(err, "could not decode auth token cache")
+	}
+
+	if time.Now().After(entry.ExpiresAt) {
+		return "", false, nil
+	}
+
+	return entry.Token, true, nil
+}
+
+// Invalidate removes the cached token, forcing the next run back through the
+// browser/SAML flow. HandleAuthFailed calls this whenever OpenVPN reports
+// AUTH_FAILED with caching enabled, so a rejected token is never reused.
+func (c *AuthTokenCache) Invalidate() error {
+	if err := os.Remove(c.Path); err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "could not remove auth token cache")
+	}
+	return nil
+}
+
+// AuthSession drives a single samlvpn run's auth token through the cache:
+// reusing a cached token when one is valid, and remembering whether it did
+// so to decide what AUTH_FAILED should do.
+type AuthSession struct {
+	cache     *AuthTokenCache
+	ttl       time.Duration
+	enabled   bool
+	usedCache bool
+}
+
+// NewAuthSession returns an AuthSession configured from c. If
+// c.CacheAuthToken is false, Token always runs samlLogin and AUTH_FAILED is
+// never treated specially.
+func NewAuthSession(c *Config) *AuthSession {
+	return &AuthSession{
+		cache:   NewAuthTokenCache(c.AuthTokenCachePath, os.FileMode(c.TempCredentialsPermissions)),
+		ttl:     c.AuthTokenTTL,
+		enabled: c.CacheAuthToken,
+	}
+}
+
+// Token returns a SAML auth token, reusing a cached one if caching is
+// enabled and a non-expired entry exists. Otherwise it calls samlLogin to
+// run the browser/SAML callback flow and, if caching is enabled, saves the
+// result for next time.
+func (s *AuthSession) Token(samlLogin func() (string, error)) (string, error) {
+	if s.enabled {
+		if token, ok, err := s.cache.Load(); err != nil {
+			return "", err
+		} else if ok {
+			s.usedCache = true
+			return token, nil
+		}
+	}
+	s.usedCache = false
+
+	token, err := samlLogin()
+	if err != nil {
+		return "", err
+	}
+
+	if s.enabled {
+		if err := s.cache.Save(token, s.ttl); err != nil {
+			return "", err
+		}
+	}
+
+	return token, nil
+}
+
+// HandleAuthFailed is called when OpenVPN reports AUTH_FAILED. If caching is
+// enabled, the cache is invalidated. retryWithoutCounting is true only if the
+// token fed to OpenVPN came from the cache: the failure was caused by a stale
+// cache entry rather than the user's credentials, so it shouldn't count
+// against Config.AuthFailedRetries.
+func (s *AuthSession) HandleAuthFailed() (retryWithoutCounting bool, err error) {
+	if !s.enabled {
+		return false, nil
+	}
+	if err := s.cache.Invalidate(); err != nil {
+		return false, err
+	}
+	return s.usedCache, nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAuthSessionSkipsSAMLLoginOnValidCache(t *testing.T) {
+	c := &Config{
+		CacheAuthToken:             true,
+		AuthTokenCachePath:         filepath.Join(t.TempDir(), "auth-token"),
+		AuthTokenTTL:               time.Hour,
+		TempCredentialsPermissions: 0400,
+	}
+
+	loginCalls := 0
+	samlLogin := func() (string, error) {
+		loginCalls++
+		return "fresh-token", nil
+	}
+
+	session := NewAuthSession(c)
+
+	token, err := session.Token(samlLogin)
+	if err != nil {
+		t.Fatalf("Token returned unexpected error: %v", err)
+	}
+	if token != "fresh-token" {
+		t.Errorf("Token = %q, want %q", token, "fresh-token")
+	}
+	if loginCalls != 1 {
+		t.Fatalf("loginCalls = %d, want 1", loginCalls)
+	}
+
+	// A second session, as if samlvpn were run again, should reuse the
+	// cached token without calling samlLogin.
+	session2 := NewAuthSession(c)
+	token, err = session2.Token(samlLogin)
+	if err != nil {
+		t.Fatalf("Token returned unexpected error: %v", err)
+	}
+	if token != "fresh-token" {
+		t.Errorf("Token = %q, want %q", token, "fresh-token")
+	}
+	if loginCalls != 1 {
+		t.Errorf("loginCalls = %d, want 1 (cached token should have been reused)", loginCalls)
+	}
+}
+
+func TestAuthSessionHandleAuthFailedInvalidatesCachedToken(t *testing.T) {
+	c := &Config{
+		CacheAuthToken:             true,
+		AuthTokenCachePath:         filepath.Join(t.TempDir(), "auth-token"),
+		AuthTokenTTL:               time.Hour,
+		TempCredentialsPermissions: 0400,
+	}
+
+	session := NewAuthSession(c)
+	if _, err := session.Token(func() (string, error) { return "stale-token", nil }); err != nil {
+		t.Fatalf("Token returned unexpected error: %v", err)
+	}
+
+	session2 := NewAuthSession(c)
+	if _, err := session2.Token(func() (string, error) { return "stale-token", nil }); err != nil {
+		t.Fatalf("Token returned unexpected error: %v", err)
+	}
+
+	retry, err := session2.HandleAuthFailed()
+	if err != nil {
+		t.Fatalf("HandleAuthFailed returned unexpected error: %v", err)
+	}
+	if !retry {
+		t.Error("HandleAuthFailed returned retryWithoutCounting = false, want true for a cache-sourced token")
+	}
+
+	if _, ok, err := session2.cache.Load(); err != nil {
+		t.Fatalf("Load returned unexpected error: %v", err)
+	} else if ok {
+		t.Error("cache still has a token after HandleAuthFailed invalidated it")
+	}
+}
+
+func TestAuthSessionDisabledAlwaysCallsSAMLLogin(t *testing.T) {
+	c := &Config{
+		CacheAuthToken:             false,
+		AuthTokenCachePath:         filepath.Join(t.TempDir(), "auth-token"),
+		AuthTokenTTL:               time.Hour,
+		TempCredentialsPermissions: 0400,
+	}
+
+	loginCalls := 0
+	samlLogin := func() (string, error) {
+		loginCalls++
+		return "token", nil
+	}
+
+	session := NewAuthSession(c)
+	if _, err := session.Token(samlLogin); err != nil {
+		t.Fatalf("Token returned unexpected error: %v", err)
+	}
+	session2 := NewAuthSession(c)
+	if _, err := session2.Token(samlLogin); err != nil {
+		t.Fatalf("Token returned unexpected error: %v", err)
+	}
+
+	if loginCalls != 2 {
+		t.Errorf("loginCalls = %d, want 2 (caching disabled, so every run should re-login)", loginCalls)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"io"
 	"os"
 	"path"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -16,40 +17,62 @@ import (
 
 type Config struct {
 	// OpenVPNBinary is the absolute path to the patched OpenVPN binary.
-	OpenVPNBinary string `yaml:"openvpn-binary"`
+	OpenVPNBinary string `yaml:"openvpn-binary" env:"SAMLVPN_OPENVPN_BINARY"`
 
 	// OpenVPNConfigFile is the absolute path to the OpenVPN config file.
-	OpenVPNConfigFile string `yaml:"openvpn-config-file"`
+	OpenVPNConfigFile string `yaml:"openvpn-config-file" env:"SAMLVPN_OPENVPN_CONFIG_FILE"`
 
 	// ServerAddress is the address on which to serve to receive the SAML
 	// callback.
-	ServerAddress string `yaml:"server-address"`
+	ServerAddress string `yaml:"server-address" env:"SAMLVPN_SERVER_ADDRESS"`
 
 	// ServerTimeout is the maximum amount of time to wait before closing the
 	// server waiting for the SAML callback.
-	ServerTimeout time.Duration `yaml:"server-timeout"`
+	ServerTimeout time.Duration `yaml:"server-timeout" env:"SAMLVPN_SERVER_TIMEOUT"`
 
 	// BrowserCommand is the format to run to open the SAML authorization URL.
-	BrowserCommand []string `yaml:"browser-command"`
+	BrowserCommand []string `yaml:"browser-command" env:"SAMLVPN_BROWSER_COMMAND"`
 
 	// RedirectURL is an optional URL to redirect the user to after a
 	// successful connection.
-	RedirectURL string `yaml:"redirect-url"`
+	RedirectURL string `yaml:"redirect-url" env:"SAMLVPN_REDIRECT_URL"`
 
 	// RunCommand determines whether to run the command or to output the
 	// command to stdout.
-	RunCommand bool `yaml:"run-command"`
+	RunCommand bool `yaml:"run-command" env:"SAMLVPN_RUN_COMMAND"`
 
 	// Retries to run OpenVPN if the VPN returns AUTH_FAILED.
-	AuthFailedRetries int `yaml:"auth-failed-retries"`
+	AuthFailedRetries int `yaml:"auth-failed-retries" env:"SAMLVPN_AUTH_FAILED_RETRIES"`
 
 	// TempCredentialsFilePath is the location to save the temporary
 	// credentials file.
-	TempCredentialsFilePath string `yaml:"temp-credentials-file-path"`
+	TempCredentialsFilePath string `yaml:"temp-credentials-file-path" env:"SAMLVPN_TEMP_CREDENTIALS_FILE_PATH"`
 
 	// TempCredentialsPermissions is the permissions for the temp credentials
 	// file.
-	TempCredentialsPermissions uint `yaml:"temp-credentials-permission"`
+	TempCredentialsPermissions uint `yaml:"temp-credentials-permission" env:"SAMLVPN_TEMP_CREDENTIALS_PERMISSION"`
+
+	// CacheAuthToken determines whether a successful SAML auth token is
+	// cached to disk so later runs can skip the browser/SAML flow entirely.
+	CacheAuthToken bool `yaml:"cache-auth-token" env:"SAMLVPN_CACHE_AUTH_TOKEN"`
+
+	// AuthTokenCachePath is the location to save the cached auth token.
+	AuthTokenCachePath string `yaml:"auth-token-cache-path" env:"SAMLVPN_AUTH_TOKEN_CACHE_PATH"`
+
+	// AuthTokenTTL is how long a cached auth token is trusted before
+	// samlvpn falls back to the browser/SAML flow again.
+	AuthTokenTTL time.Duration `yaml:"auth-token-ttl" env:"SAMLVPN_AUTH_TOKEN_TTL"`
+
+	// StrictPermissions turns the openvpn-config-file readability warning in
+	// Validate into a hard error. It may contain embedded keys, so a
+	// group- or world-readable file is a real exposure on shared machines.
+	StrictPermissions bool `yaml:"strict-permissions" env:"SAMLVPN_STRICT_PERMISSIONS"`
+
+	// ExtraOpenVPNArgs are additional flags to pass to OpenVPN, validated by
+	// SanitizeOpenVPNArgs against a fixed allowlist before exec. This lets
+	// power users override things like --cipher or --keepalive from YAML
+	// without opening a hole for arbitrary flags.
+	ExtraOpenVPNArgs []string `yaml:"extra-openvpn-args" env:"SAMLVPN_EXTRA_OPENVPN_ARGS"`
 }
 
 // DefaultCredsFilePath returns an absolute path to the default location for
@@ -61,6 +84,15 @@ func DefaultCredsFilePath() string {
 	return path.Join(os.Getenv("HOME"), ".samlvpn-credentials")
 }
 
+// DefaultAuthTokenCachePath returns an absolute path to the default location
+// for the cached SAML auth token.
+func DefaultAuthTokenCachePath() string {
+	if cachedir, err := os.UserCacheDir(); err == nil {
+		return path.Join(cachedir, "/samlvpn-auth-token")
+	}
+	return path.Join(os.Getenv("HOME"), ".samlvpn-auth-token")
+}
+
 // ParseWithDefaults parses the contents of r into c. It also sets defaults for
 // optionals if the parsed file didn't override them.
 func (c *Config) ParseWithDefaults(r io.Reader) error {
@@ -68,6 +100,10 @@ func (c *Config) ParseWithDefaults(r io.Reader) error {
 		return errors.Wrap(err, "could not decode configuration file")
 	}
 
+	if err := applyEnvOverrides(c); err != nil {
+		return errors.Wrap(err, "could not apply environment variable overrides")
+	}
+
 	if c.ServerAddress == "" {
 		c.ServerAddress = "0.0.0.0:35001"
 	}
@@ -82,6 +118,13 @@ func (c *Config) ParseWithDefaults(r io.Reader) error {
 		c.TempCredentialsPermissions = 0400
 	}
 
+	if c.AuthTokenCachePath == "" {
+		c.AuthTokenCachePath = DefaultAuthTokenCachePath()
+	}
+	if c.AuthTokenTTL == 0 {
+		c.AuthTokenTTL = time.Hour * 8
+	}
+
 	return nil
 }
 
@@ -116,42 +159,324 @@ func (c *Config) Validate() []error {
 		errs = append(errs, errors.New("the browser-command must contain %s"))
 	}
 
+	errs = append(errs, checkTempCredentialsPermissions(c.TempCredentialsFilePath, os.FileMode(c.TempCredentialsPermissions))...)
+	errs = append(errs, checkOpenVPNConfigPermissions(c.OpenVPNConfigFile, c.StrictPermissions)...)
+
+	if _, err := SanitizeOpenVPNArgs(c.ExtraOpenVPNArgs); err != nil {
+		errs = append(errs, errors.Wrap(err, "invalid extra-openvpn-args"))
+	}
+
 	return errs
 }
 
+// Remote is a single `remote` directive from an OpenVPN config, in the order
+// it appeared in the file. samlvpn uses the order to round-robin or fail
+// over between servers.
+type Remote struct {
+	Host  string
+	Port  int
+	Proto string
+}
+
+// OpenVPNConfig is the result of parsing an OpenVPN config file down to the
+// directives samlvpn cares about.
 type OpenVPNConfig struct {
-	Host     string
-	Port     int
+	// Remotes holds every `remote` line found, in file order.
+	Remotes []Remote
+
+	// Protocol is the default transport from a top-level `proto` directive.
+	// An explicit proto on a `remote` line overrides this per-remote.
 	Protocol string
+
+	// Directives holds the raw arguments of every other recognized
+	// directive, keyed by directive name.
+	Directives map[string][]string
+}
+
+// ConfigLineError describes why a single line of an OpenVPN config was
+// rejected.
+type ConfigLineError struct {
+	Line int
+	Text string
+	Err  error
+}
+
+func (e *ConfigLineError) Error() string {
+	return fmt.Sprintf("line %d: %q: %v", e.Line, e.Text, e.Err)
+}
+
+// ParseError collects every ConfigLineError found while parsing an OpenVPN
+// config, so a user can fix all of them in one pass instead of one at a
+// time.
+type ParseError struct {
+	Errors []*ConfigLineError
+}
+
+func (e *ParseError) Error() string {
+	lines := make([]string, len(e.Errors))
+	for i, lineErr := range e.Errors {
+		lines[i] = lineErr.Error()
+	}
+	return fmt.Sprintf("invalid openvpn config (%d line(s)):\n%s", len(e.Errors), strings.Join(lines, "\n"))
 }
 
+// openVPNDirectiveSafelist maps a recognized OpenVPN directive to a
+// validator for its arguments. This is the same technique the LEAP riseup
+// helper uses to keep an arbitrary config file from smuggling in directives
+// samlvpn doesn't expect. A directive that isn't in this map is rejected.
+var openVPNDirectiveSafelist = map[string]func(args []string) error{
+	"remote":          validateRemoteArgs,
+	"proto":           validateProtoArgs,
+	"port":            validatePortArgs,
+	"rport":           validatePortArgs,
+	"auth":            validateSingleArg,
+	"cipher":          validateSingleArg,
+	"tls-cipher":      validateSingleArg,
+	"remote-cert-tls": validateRemoteCertTLSArgs,
+	"fragment":        validateIntArg,
+	"keepalive":       validateKeepaliveArgs,
+	"verb":            validateIntArg,
+
+	// Directives present in essentially every real-world OpenVPN client
+	// config.
+	"client":        validateNoArgs,
+	"dev":           validateDevArgs,
+	"resolv-retry":  validateResolvRetryArgs,
+	"nobind":        validateNoArgs,
+	"persist-key":   validateNoArgs,
+	"persist-tun":   validateNoArgs,
+	"ca":            validateSingleArg,
+	"cert":          validateSingleArg,
+	"key":           validateSingleArg,
+	"tls-auth":      validateTLSAuthArgs,
+	"tls-crypt":     validateSingleArg,
+	"key-direction": validateKeyDirectionArgs,
+	"comp-lzo":      validateOptionalSingleArg,
+	"compress":      validateOptionalSingleArg,
+	"mute":          validateIntArg,
+}
+
+func validateRemoteArgs(args []string) error {
+	if len(args) < 2 || len(args) > 3 {
+		return errors.New("remote requires a host and port, and an optional proto")
+	}
+	if _, err := strconv.ParseInt(args[1], 10, 64); err != nil {
+		return errors.Wrap(err, "remote has non-integer port")
+	}
+	if len(args) == 3 {
+		return validateProto(args[2])
+	}
+	return nil
+}
+
+func validateProtoArgs(args []string) error {
+	if len(args) != 1 {
+		return errors.New("proto takes exactly one argument")
+	}
+	return validateProto(args[0])
+}
+
+func validateProto(proto string) error {
+	switch proto {
+	case "tcp", "udp", "tcp-client", "udp-client":
+		return nil
+	default:
+		return errors.Errorf("unrecognized proto %q", proto)
+	}
+}
+
+func validatePortArgs(args []string) error {
+	return validateIntArg(args)
+}
+
+func validateIntArg(args []string) error {
+	if len(args) != 1 {
+		return errors.New("expected exactly one integer argument")
+	}
+	if _, err := strconv.ParseInt(args[0], 10, 64); err != nil {
+		return errors.Wrap(err, "expected an integer argument")
+	}
+	return nil
+}
+
+func validateSingleArg(args []string) error {
+	if len(args) != 1 {
+		return errors.New("expected exactly one argument")
+	}
+	return nil
+}
+
+func validateRemoteCertTLSArgs(args []string) error {
+	if len(args) != 1 {
+		return errors.New("remote-cert-tls takes exactly one argument")
+	}
+	switch args[0] {
+	case "server", "client":
+		return nil
+	default:
+		return errors.Errorf("unrecognized remote-cert-tls value %q", args[0])
+	}
+}
+
+func validateKeepaliveArgs(args []string) error {
+	if len(args) != 2 {
+		return errors.New("keepalive requires two integer arguments")
+	}
+	if _, err := strconv.ParseInt(args[0], 10, 64); err != nil {
+		return errors.Wrap(err, "keepalive has non-integer first argument")
+	}
+	if _, err := strconv.ParseInt(args[1], 10, 64); err != nil {
+		return errors.Wrap(err, "keepalive has non-integer second argument")
+	}
+	return nil
+}
+
+func validateNoArgs(args []string) error {
+	if len(args) != 0 {
+		return errors.New("expected no arguments")
+	}
+	return nil
+}
+
+func validateOptionalSingleArg(args []string) error {
+	if len(args) > 1 {
+		return errors.New("expected at most one argument")
+	}
+	return nil
+}
+
+var devPattern = regexp.MustCompile(`^(tun|tap)[0-9]*$`)
+
+func validateDevArgs(args []string) error {
+	if len(args) != 1 {
+		return errors.New("dev takes exactly one argument")
+	}
+	if !devPattern.MatchString(args[0]) {
+		return errors.Errorf("unrecognized dev %q", args[0])
+	}
+	return nil
+}
+
+func validateResolvRetryArgs(args []string) error {
+	if len(args) != 1 {
+		return errors.New("resolv-retry takes exactly one argument")
+	}
+	if args[0] == "infinite" {
+		return nil
+	}
+	if _, err := strconv.ParseInt(args[0], 10, 64); err != nil {
+		return errors.Errorf("resolv-retry must be %q or an integer", "infinite")
+	}
+	return nil
+}
+
+func validateTLSAuthArgs(args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		return errors.New("tls-auth requires a file and an optional key direction")
+	}
+	if len(args) == 2 {
+		return validateKeyDirectionArgs(args[1:])
+	}
+	return nil
+}
+
+func validateKeyDirectionArgs(args []string) error {
+	if len(args) != 1 {
+		return errors.New("key-direction takes exactly one argument")
+	}
+	switch args[0] {
+	case "0", "1":
+		return nil
+	default:
+		return errors.Errorf("key-direction must be 0 or 1, got %q", args[0])
+	}
+}
+
+// ParseOpenVPNConfig tokenizes an OpenVPN config file, validating every
+// directive against openVPNDirectiveSafelist. Comments (`#` or `;`), blank
+// lines, and `<tag>...</tag>` inline blocks are skipped. Directives that
+// aren't recognized, or that have the wrong number or type of arguments, are
+// collected into a *ParseError so the caller can report every bad line at
+// once rather than stopping at the first.
 func ParseOpenVPNConfig(r io.Reader) (*OpenVPNConfig, error) {
-	config := &OpenVPNConfig{}
+	config := &OpenVPNConfig{
+		Directives: map[string][]string{},
+	}
+	var lineErrors []*ConfigLineError
 
 	scanner := bufio.NewScanner(r)
+	lineNum := 0
 	for scanner.Scan() {
-		line := scanner.Text()
-		parts := strings.Split(line, " ")
-		if len(parts) < 2 {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
 			continue
 		}
 
-		switch parts[0] {
-		case "remote":
-			if len(parts[1:]) != 2 {
-				return nil, fmt.Errorf("remote line does not include host and port")
-			}
-			config.Host = parts[1]
-			port, err := strconv.ParseInt(parts[2], 10, 64)
+		if strings.HasPrefix(line, "<") {
+			var err error
+			lineNum, err = skipInlineBlock(scanner, line, lineNum)
 			if err != nil {
-				return nil, errors.Wrap(err, "remote line has non-integer port")
+				lineErrors = append(lineErrors, &ConfigLineError{Line: lineNum, Text: line, Err: err})
 			}
-			config.Port = int(port)
+			continue
+		}
 
+		fields := strings.Fields(line)
+		directive, args := fields[0], fields[1:]
+
+		validate, ok := openVPNDirectiveSafelist[directive]
+		if !ok {
+			lineErrors = append(lineErrors, &ConfigLineError{
+				Line: lineNum,
+				Text: line,
+				Err:  errors.Errorf("%q is not a recognized directive", directive),
+			})
+			continue
+		}
+		if err := validate(args); err != nil {
+			lineErrors = append(lineErrors, &ConfigLineError{Line: lineNum, Text: line, Err: err})
+			continue
+		}
+
+		switch directive {
+		case "remote":
+			remote := Remote{Host: args[0]}
+			port, _ := strconv.ParseInt(args[1], 10, 64)
+			remote.Port = int(port)
+			if len(args) == 3 {
+				remote.Proto = args[2]
+			}
+			config.Remotes = append(config.Remotes, remote)
 		case "proto":
-			config.Protocol = parts[1]
+			config.Protocol = args[0]
+		default:
+			config.Directives[directive] = args
 		}
 	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, "could not read openvpn config")
+	}
+
+	if len(lineErrors) > 0 {
+		return nil, &ParseError{Errors: lineErrors}
+	}
 
 	return config, nil
 }
+
+// skipInlineBlock consumes lines from scanner until it finds the closing tag
+// matching the `<tag>` opening line, returning the line number of the
+// closing tag. It returns an error if the block is never closed.
+func skipInlineBlock(scanner *bufio.Scanner, openTag string, lineNum int) (int, error) {
+	tag := strings.TrimSuffix(strings.TrimPrefix(openTag, "<"), ">")
+	closeTag := "</" + tag + ">"
+
+	for scanner.Scan() {
+		lineNum++
+		if strings.TrimSpace(scanner.Text()) == closeTag {
+			return lineNum, nil
+		}
+	}
+	return lineNum, errors.Errorf("%q block is never closed", openTag)
+}
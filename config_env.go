@@ -0,0 +1,145 @@
+package main
+
+import (
+	"os"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// applyEnvOverrides fills in Config fields from their `env` struct tag,
+// mirroring the precedence cloudflared and similar CLIs use: YAML -> env ->
+// default. It's driven entirely by reflection over the `env` tags on Config,
+// so a new field only needs a tag to get an environment variable binding,
+// with no further boilerplate here.
+func applyEnvOverrides(c *Config) error {
+	v := reflect.ValueOf(c).Elem()
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		envVar := field.Tag.Get("env")
+		if envVar == "" {
+			continue
+		}
+
+		if !v.Field(i).IsZero() {
+			// YAML already set this field, and env is only a fallback for
+			// whatever YAML left unset. A field counts as unset while it
+			// holds its zero value, so a YAML value of false, 0 or "" is
+			// overridden by env too.
+			continue
+		}
+
+		raw, ok := os.LookupEnv(envVar)
+		if !ok {
+			continue
+		}
+
+		if err := setFieldFromEnv(v.Field(i), raw); err != nil {
+			return errors.Wrapf(err, "%s", envVar)
+		}
+	}
+
+	return nil
+}
+
+// setFieldFromEnv parses raw according to field's type and sets it.
+func setFieldFromEnv(field reflect.Value, raw string) error {
+	if field.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return errors.Wrap(err, "not a valid duration")
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(raw)
+
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return errors.Wrap(err, "not a valid bool")
+		}
+		field.SetBool(b)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return errors.Wrap(err, "not a valid integer")
+		}
+		field.SetInt(n)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		// Base 0 auto-detects a leading-zero octal literal (e.g. "0400"),
+		// matching how gopkg.in/yaml.v2 resolves the same literal from YAML
+		// so a permission mode means the same thing from either source.
+		n, err := strconv.ParseUint(raw, 0, 64)
+		if err != nil {
+			return errors.Wrap(err, "not a valid unsigned integer")
+		}
+		field.SetUint(n)
+
+	case reflect.Slice:
+		if field.Type().Elem().Kind() != reflect.String {
+			return errors.Errorf("unsupported slice type %s", field.Type())
+		}
+		field.Set(reflect.ValueOf(strings.Split(raw, ",")))
+
+	default:
+		return errors.Errorf("unsupported field type %s", field.Type())
+	}
+
+	return nil
+}
+
+// standardConfigPaths returns the locations LoadConfig searches, in priority
+// order, mirroring the multi-location discovery cloudflared uses.
+func standardConfigPaths() []string {
+	var paths []string
+
+	if xdgConfigHome := os.Getenv("XDG_CONFIG_HOME"); xdgConfigHome != "" {
+		paths = append(paths, path.Join(xdgConfigHome, "samlvpn", "config.yaml"))
+	}
+
+	if home := os.Getenv("HOME"); home != "" {
+		paths = append(paths, path.Join(home, ".config", "samlvpn", "config.yaml"))
+		paths = append(paths, path.Join(home, ".samlvpn.yaml"))
+	}
+
+	paths = append(paths, "/etc/samlvpn/config.yaml")
+
+	return paths
+}
+
+// LoadConfig searches standardConfigPaths in order and parses the first one
+// that exists, applying the usual YAML -> env -> default precedence.
+func LoadConfig() (*Config, error) {
+	for _, configPath := range standardConfigPaths() {
+		f, err := os.Open(configPath)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, errors.Wrapf(err, "could not open %s", configPath)
+		}
+
+		c := &Config{}
+		err = c.ParseWithDefaults(f)
+		f.Close()
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not parse %s", configPath)
+		}
+
+		return c, nil
+	}
+
+	return nil, errors.New("no samlvpn config file found in any standard location")
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseWithDefaultsEnvPrecedence(t *testing.T) {
+	t.Setenv("SAMLVPN_SERVER_ADDRESS", "9.9.9.9:1")
+
+	c := &Config{}
+	yamlConfig := "server-address: 1.2.3.4:5\nbrowser-command: [\"open\", \"%s\"]\n"
+	if err := c.ParseWithDefaults(strings.NewReader(yamlConfig)); err != nil {
+		t.Fatalf("ParseWithDefaults returned unexpected error: %v", err)
+	}
+
+	if got, want := c.ServerAddress, "1.2.3.4:5"; got != want {
+		t.Errorf("ServerAddress = %q, want %q (YAML must win over env)", got, want)
+	}
+}
+
+func TestParseWithDefaultsEnvFallback(t *testing.T) {
+	t.Setenv("SAMLVPN_SERVER_ADDRESS", "9.9.9.9:1")
+
+	c := &Config{}
+	yamlConfig := "browser-command: [\"open\", \"%s\"]\n"
+	if err := c.ParseWithDefaults(strings.NewReader(yamlConfig)); err != nil {
+		t.Fatalf("ParseWithDefaults returned unexpected error: %v", err)
+	}
+
+	if got, want := c.ServerAddress, "9.9.9.9:1"; got != want {
+		t.Errorf("ServerAddress = %q, want %q (env must fill in what YAML left unset)", got, want)
+	}
+}
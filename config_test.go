@@ -0,0 +1,92 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseOpenVPNConfigRealWorldClientConfig(t *testing.T) {
+	const config = `
+client
+dev tun
+proto udp
+remote vpn.example.com 1194
+resolv-retry infinite
+nobind
+persist-key
+persist-tun
+ca ca.crt
+cert client.crt
+key client.key
+tls-auth ta.key 1
+remote-cert-tls server
+cipher AES-256-GCM
+verb 3
+`
+
+	parsed, err := ParseOpenVPNConfig(strings.NewReader(config))
+	if err != nil {
+		t.Fatalf("ParseOpenVPNConfig returned unexpected error: %v", err)
+	}
+
+	if parsed.Protocol != "udp" {
+		t.Errorf("Protocol = %q, want %q", parsed.Protocol, "udp")
+	}
+
+	if len(parsed.Remotes) != 1 {
+		t.Fatalf("len(Remotes) = %d, want 1", len(parsed.Remotes))
+	}
+	if got, want := parsed.Remotes[0].Host, "vpn.example.com"; got != want {
+		t.Errorf("Remotes[0].Host = %q, want %q", got, want)
+	}
+	if got, want := parsed.Remotes[0].Port, 1194; got != want {
+		t.Errorf("Remotes[0].Port = %d, want %d", got, want)
+	}
+
+	for _, directive := range []string{"client", "dev", "resolv-retry", "nobind", "persist-key", "persist-tun", "ca", "cert", "key", "tls-auth", "cipher", "verb"} {
+		if _, ok := parsed.Directives[directive]; !ok {
+			t.Errorf("Directives[%q] missing", directive)
+		}
+	}
+}
+
+func TestParseOpenVPNConfigRejectsUnrecognizedDirective(t *testing.T) {
+	const config = "up /etc/openvpn/update-resolv-conf\n"
+
+	_, err := ParseOpenVPNConfig(strings.NewReader(config))
+	if err == nil {
+		t.Fatal("ParseOpenVPNConfig returned no error for an unrecognized directive")
+	}
+
+	parseErr, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("error is %T, want *ParseError", err)
+	}
+	if len(parseErr.Errors) != 1 {
+		t.Fatalf("len(Errors) = %d, want 1", len(parseErr.Errors))
+	}
+	if parseErr.Errors[0].Line != 1 {
+		t.Errorf("Errors[0].Line = %d, want 1", parseErr.Errors[0].Line)
+	}
+}
+
+func TestParseOpenVPNConfigSkipsCommentsAndBlocks(t *testing.T) {
+	const config = `
+# a comment
+; also a comment
+<ca>
+-----BEGIN CERTIFICATE-----
+...
+-----END CERTIFICATE-----
+</ca>
+client
+`
+
+	parsed, err := ParseOpenVPNConfig(strings.NewReader(config))
+	if err != nil {
+		t.Fatalf("ParseOpenVPNConfig returned unexpected error: %v", err)
+	}
+	if len(parsed.Remotes) != 0 {
+		t.Errorf("len(Remotes) = %d, want 0", len(parsed.Remotes))
+	}
+}
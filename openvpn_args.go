@@ -0,0 +1,136 @@
+package main
+
+import (
+	"net"
+	"regexp"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// baseOpenVPNArgs are the flags samlvpn always passes to OpenVPN, ahead of
+// anything from Config.ExtraOpenVPNArgs.
+var baseOpenVPNArgs = []string{
+	"--nobind",
+	"--client",
+	"--tls-client",
+	"--remote-cert-tls", "server",
+}
+
+// argType validates a single OpenVPN flag argument.
+type argType func(string) error
+
+var (
+	argHost   argType = validateArgHost
+	argNumber argType = validateArgNumber
+	argProto  argType = validateArgProto
+	argCipher argType = validateArgCipher
+	argFile   argType = validateArgFile
+)
+
+var hostnamePattern = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9-]*[A-Za-z0-9])?(\.[A-Za-z0-9]([A-Za-z0-9-]*[A-Za-z0-9])?)*$`)
+
+// validateArgHost accepts anything --remote would: an IP address or a DNS
+// hostname.
+func validateArgHost(s string) error {
+	if net.ParseIP(s) != nil {
+		return nil
+	}
+	if !hostnamePattern.MatchString(s) {
+		return errors.Errorf("%q is not a valid host", s)
+	}
+	return nil
+}
+
+func validateArgNumber(s string) error {
+	if _, err := strconv.ParseInt(s, 10, 64); err != nil {
+		return errors.Errorf("%q is not a number", s)
+	}
+	return nil
+}
+
+func validateArgProto(s string) error {
+	switch s {
+	case "tcp", "udp", "tcp-client", "udp-client":
+		return nil
+	default:
+		return errors.Errorf("%q is not a recognized proto", s)
+	}
+}
+
+var cipherPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]*$`)
+
+func validateArgCipher(s string) error {
+	if !cipherPattern.MatchString(s) {
+		return errors.Errorf("%q is not a valid cipher/algorithm name", s)
+	}
+	return nil
+}
+
+var filePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_./-]*$`)
+
+func validateArgFile(s string) error {
+	if !filePattern.MatchString(s) {
+		return errors.Errorf("%q is not a valid file path", s)
+	}
+	return nil
+}
+
+// openVPNArgSafelist maps an allowed pass-through OpenVPN flag to the
+// validators for each argument it takes, modeled on riseup_vpn's
+// helper/args.go. A flag not in this map, or with the wrong number of
+// arguments, is rejected: this is what keeps an attacker who can edit the
+// YAML config from smuggling in something like --up or --script-security to
+// run arbitrary code.
+//
+// --remote-cert-tls is deliberately not here: baseOpenVPNArgs already forces
+// it to "server", and OpenVPN takes the last occurrence of a repeated flag,
+// so allowing it through here would let an extra arg silently downgrade the
+// exact property it's hardcoded to enforce.
+var openVPNArgSafelist = map[string][]argType{
+	"--cipher":     {argCipher},
+	"--tls-cipher": {argCipher},
+	"--auth":       {argCipher},
+	"--fragment":   {argNumber},
+	"--keepalive":  {argNumber, argNumber},
+	"--rport":      {argNumber},
+	"--port":       {argNumber},
+	"--proto":      {argProto},
+	"--remote":     {argHost, argNumber},
+	"--ca":         {argFile},
+	"--cert":       {argFile},
+	"--key":        {argFile},
+	"--tls-auth":   {argFile},
+	"--verb":       {argNumber},
+}
+
+// SanitizeOpenVPNArgs validates extra against openVPNArgSafelist and, if
+// every flag is recognized with the right argument count and types, returns
+// the full argument list to exec: baseOpenVPNArgs followed by extra.
+func SanitizeOpenVPNArgs(extra []string) ([]string, error) {
+	for i := 0; i < len(extra); {
+		flag := extra[i]
+		types, ok := openVPNArgSafelist[flag]
+		if !ok {
+			return nil, errors.Errorf("%q is not an allowed openvpn argument", flag)
+		}
+
+		if i+1+len(types) > len(extra) {
+			return nil, errors.Errorf("%q requires %d argument(s)", flag, len(types))
+		}
+
+		for j, validate := range types {
+			arg := extra[i+1+j]
+			if err := validate(arg); err != nil {
+				return nil, errors.Wrapf(err, "invalid argument to %q", flag)
+			}
+		}
+
+		i += 1 + len(types)
+	}
+
+	args := make([]string, 0, len(baseOpenVPNArgs)+len(extra))
+	args = append(args, baseOpenVPNArgs...)
+	args = append(args, extra...)
+	return args, nil
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+// worldWritable and friends mirror the permission bits iVPN's daemon checks
+// in checkFileAccessRightsStaticConfig: a world-writable directory lets any
+// local user replace samlvpn's files out from under it, and a
+// group/world-readable config can leak embedded keys.
+const (
+	worldWritable = 0002
+	groupReadable = 0040
+	worldReadable = 0004
+)
+
+// checkTempCredentialsPermissions refuses to proceed if the directory that
+// will hold the temporary credentials file is world-writable, or if the
+// file already exists with permissions looser than maxPerm or owned by
+// someone other than the current user.
+func checkTempCredentialsPermissions(path string, maxPerm os.FileMode) []error {
+	if path == "" {
+		return nil
+	}
+	var errs []error
+
+	dir := filepath.Dir(path)
+	if dirInfo, err := os.Stat(dir); err != nil {
+		errs = append(errs, errors.Wrapf(err, "could not stat %s", dir))
+	} else if dirInfo.Mode().Perm()&worldWritable != 0 {
+		errs = append(errs, errors.Errorf("%s is world-writable, refusing to use it for credentials", dir))
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		// The file doesn't exist yet; it'll be created fresh with the
+		// right permissions, so there's nothing to check.
+		return errs
+	}
+
+	if info.Mode().Perm()&^maxPerm.Perm() != 0 {
+		errs = append(errs, errors.Errorf("%s has permissions %04o, which is looser than the configured %04o", path, info.Mode().Perm(), maxPerm.Perm()))
+	}
+
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		if uid := os.Getuid(); int(stat.Uid) != uid {
+			errs = append(errs, errors.Errorf("%s is not owned by the current user", path))
+		}
+	}
+
+	return errs
+}
+
+// checkOpenVPNConfigPermissions flags an openvpn-config-file that's
+// readable by anyone other than its owner, since it may contain embedded
+// keys and certificates. It's a warning unless strict is set, in which case
+// it's an error.
+func checkOpenVPNConfigPermissions(path string, strict bool) []error {
+	if path == "" {
+		return nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil // already reported by the caller's own os.Stat check
+	}
+
+	if info.Mode().Perm()&(groupReadable|worldReadable) == 0 {
+		return nil
+	}
+
+	msg := errors.Errorf("%s is readable by other users, which may expose embedded keys", path)
+	if strict {
+		return []error{msg}
+	}
+
+	fmtWarning(msg)
+	return nil
+}
+
+// CheckCredentialsWritable runs the same credentials-file checks as
+// Config.Validate against path, but is meant to be called again immediately before writing the
+// credentials file, since the directory or an existing file could have
+// changed between startup and the write.
+func CheckCredentialsWritable(path string, perm os.FileMode) error {
+	errs := checkTempCredentialsPermissions(path, perm)
+	if len(errs) == 0 {
+		return nil
+	}
+
+	msgs := make([]string, len(errs))
+	for i, err := range errs {
+		msgs[i] = err.Error()
+	}
+	return errors.New(strings.Join(msgs, "; "))
+}
+
+// WriteCredentialsFile writes data to path with perm permissions, after
+// running CheckCredentialsWritable against path, so the permission checks
+// are repeated at write time rather than relying solely on the one-time
+// check in Config.Validate.
+func WriteCredentialsFile(path string, perm os.FileMode, data []byte) error {
+	if err := CheckCredentialsWritable(path, perm); err != nil {
+		return errors.Wrap(err, "refusing to write credentials file")
+	}
+
+	if err := os.WriteFile(path, data, perm); err != nil {
+		return errors.Wrap(err, "could not write credentials file")
+	}
+
+	return nil
+}
+
+// fmtWarning prints a non-fatal validation warning to stderr.
+func fmtWarning(err error) {
+	os.Stderr.WriteString("warning: " + err.Error() + "\n")
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteCredentialsFileWritesNewFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "creds")
+
+	if err := WriteCredentialsFile(path, 0400, []byte("secret")); err != nil {
+		t.Fatalf("WriteCredentialsFile returned unexpected error: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile returned unexpected error: %v", err)
+	}
+	if string(data) != "secret" {
+		t.Errorf("file contents = %q, want %q", data, "secret")
+	}
+}
+
+func TestWriteCredentialsFileRefusesLooseExistingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "creds")
+	if err := os.WriteFile(path, []byte("old"), 0644); err != nil {
+		t.Fatalf("WriteFile returned unexpected error: %v", err)
+	}
+
+	err := WriteCredentialsFile(path, 0400, []byte("new"))
+	if err == nil {
+		t.Fatal("WriteCredentialsFile returned no error for a world-readable existing file")
+	}
+
+	data, readErr := os.ReadFile(path)
+	if readErr != nil {
+		t.Fatalf("ReadFile returned unexpected error: %v", readErr)
+	}
+	if string(data) != "old" {
+		t.Errorf("file contents = %q, want %q (write should have been refused)", data, "old")
+	}
+}
@@ -0,0 +1,23 @@
+// Package sandbox restricts the samlvpn process's filesystem and system call
+// access once it has finished reading its configuration and writing the
+// temporary credentials file, limiting the damage a compromised dependency
+// or a malicious OpenVPN config could do during the browser-launch and
+// management-interface phase that follows.
+package sandbox
+
+// Paths describes the filesystem paths samlvpn still needs access to after
+// Restrict is called.
+type Paths struct {
+	// OpenVPNBinary is exec'd, so it needs read+execute access.
+	OpenVPNBinary string
+
+	// OpenVPNConfigFile is only read from this point on.
+	OpenVPNConfigFile string
+
+	// TempCredentialsFilePath is read, written, and eventually removed.
+	TempCredentialsFilePath string
+
+	// BrowserCommand is the browser launcher binary, exec'd to open the
+	// SAML authorization URL.
+	BrowserCommand string
+}
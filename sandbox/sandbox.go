@@ -0,0 +1,10 @@
+//go:build !openbsd
+
+package sandbox
+
+// Restrict is a no-op on platforms without a sandboxing implementation, so
+// callers don't need build tags of their own. See sandbox_openbsd.go for the
+// OpenBSD pledge(2)/unveil(2) implementation.
+func Restrict(paths Paths) error {
+	return nil
+}
@@ -0,0 +1,55 @@
+//go:build openbsd
+
+package sandbox
+
+import (
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+// execPromises is Restrict's execpromises argument to unix.Pledge: every
+// program samlvpn execs after Restrict, OpenVPN and the browser alike, starts
+// with only these promises. samlvpn itself keeps promises.
+const execPromises = "stdio"
+
+// promises is what samlvpn needs for the remaining browser-launch and
+// management-interface phase: reading/writing the credentials file
+// (rpath/wpath/cpath), talking to the management interface and SAML
+// callback server (inet), and launching the browser and OpenVPN (exec/proc).
+const promises = "stdio rpath wpath cpath inet exec proc"
+
+// Restrict unveils only the paths samlvpn still needs and pledges down to
+// promises, the way Molly Brown uses pledge(2)/unveil(2) to restrict itself
+// after startup. It must be called after samlvpn has read its YAML config,
+// the OpenVPN config, and written the temp credentials file, since none of
+// those paths are reachable afterward beyond what's unveiled here.
+func Restrict(paths Paths) error {
+	unveils := []struct {
+		path string
+		mode string
+	}{
+		{paths.OpenVPNBinary, "rx"},
+		{paths.OpenVPNConfigFile, "r"},
+		{paths.TempCredentialsFilePath, "rwc"},
+		{paths.BrowserCommand, "rx"},
+	}
+
+	for _, u := range unveils {
+		if u.path == "" {
+			continue
+		}
+		if err := unix.Unveil(u.path, u.mode); err != nil {
+			return errors.Wrapf(err, "could not unveil %s", u.path)
+		}
+	}
+
+	if err := unix.UnveilBlock(); err != nil {
+		return errors.Wrap(err, "could not block further unveil calls")
+	}
+
+	if err := unix.Pledge(promises, execPromises); err != nil {
+		return errors.Wrap(err, "could not pledge")
+	}
+
+	return nil
+}